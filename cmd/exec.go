@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/spf13/cobra"
+
+	"github.com/codekoala/go-aws-lanes"
+)
+
+var (
+	execParallelism int
+	execFailFast    bool
+	execUpload      string
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <lane> -- <cmd>",
+	Short: "Run a command across every server in a lane",
+	Args:  cobra.MinimumNArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		lane := args[0]
+
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 {
+			dash = 1
+		}
+		command := strings.Join(args[dash:], " ")
+
+		_, prof := loadActiveProfile(cmd)
+		prof.Activate()
+
+		sess, err := session.NewSession(prof.AWSConfig())
+		if err != nil {
+			cmd.PrintErrf("ERROR: unable to start AWS session: %s\n", err)
+			os.Exit(1)
+		}
+
+		svc := ec2.New(sess)
+
+		results, err := prof.RunInLane(svc, lane, command, lanes.RunOptions{
+			Parallelism: execParallelism,
+			FailFast:    execFailFast,
+			Upload:      execUpload,
+		})
+		if err != nil {
+			cmd.PrintErrf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+
+		failures := 0
+		for _, r := range results {
+			if r.Err != nil || r.ExitCode != 0 {
+				failures++
+			}
+		}
+
+		if failures > 0 {
+			fmt.Printf("%d of %d host(s) failed\n", failures, len(results))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	fl := execCmd.Flags()
+	fl.String("profile", "default", "lanes profile to use")
+	fl.IntVarP(&execParallelism, "parallelism", "p", 5, "number of hosts to run against concurrently")
+	fl.BoolVar(&execFailFast, "fail-fast", false, "stop dispatching to new hosts after the first failure")
+	fl.StringVar(&execUpload, "upload", "", "local file or directory to copy to each host before running cmd")
+
+	RootCmd.AddCommand(execCmd)
+}
+
+// loadActiveProfile loads the profile named by this command's --profile flag (defaulting to "default").
+func loadActiveProfile(cmd *cobra.Command) (string, *lanes.Profile) {
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		name = "default"
+	}
+
+	prof, err := lanes.LoadProfile(name)
+	if err != nil {
+		cmd.PrintErrf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	return name, prof
+}
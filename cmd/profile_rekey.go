@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var rekeyRecipients []string
+
+var profileRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt a profile for a new set of recipients",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		name, prof := loadActiveProfile(cmd)
+
+		if prof.Encryption == nil {
+			cmd.PrintErrf("ERROR: profile %q is not encrypted\n", name)
+			os.Exit(1)
+		}
+
+		if len(rekeyRecipients) > 0 {
+			prof.Encryption.Recipients = rekeyRecipients
+		}
+
+		prof.SetOverwrite(true)
+		if err := prof.Write(name); err != nil {
+			cmd.PrintErrf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Profile %q rekeyed for %d recipient(s)\n", name, len(prof.Encryption.Recipients))
+	},
+}
+
+func init() {
+	profileRekeyCmd.Flags().String("profile", "default", "lanes profile to rekey")
+	profileRekeyCmd.Flags().StringSliceVar(&rekeyRecipients, "recipient", nil, "replace the profile's recipients with this list (age public keys, or a KMS/PGP/age identifier for sops)")
+
+	profileCmd.AddCommand(profileRekeyCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var profileSetSecretCmd = &cobra.Command{
+	Use:   "set-secret <path> <ref>",
+	Short: "Point a profile field at an external secret reference instead of a plaintext value",
+	Args:  cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		name, prof := loadActiveProfile(cmd)
+
+		if err := prof.SetSecretRef(args[0], args[1]); err != nil {
+			cmd.PrintErrf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+
+		prof.SetOverwrite(true)
+		if err := prof.Write(name); err != nil {
+			cmd.PrintErrf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s now references %s\n", args[0], args[1])
+	},
+}
+
+func init() {
+	profileSetSecretCmd.Flags().String("profile", "default", "lanes profile to update")
+
+	profileCmd.AddCommand(profileSetSecretCmd)
+}
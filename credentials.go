@@ -0,0 +1,190 @@
+package lanes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrNoCredentialsConfigured is returned by Credentials.Resolve when a credentials block is present but none of its
+// provider fields are set.
+var ErrNoCredentialsConfigured = fmt.Errorf("no credentials provider configured")
+
+// Credentials describes how a profile should obtain AWS credentials, as an alternative to the plaintext
+// AWSAccessKeyId/AWSSecretAccessKey fields on Profile. Exactly one of the provider blocks should be set; Resolve
+// picks whichever one is present.
+type Credentials struct {
+	Static            *StaticCredentials       `yaml:"static,omitempty"`
+	Env               *EnvCredentials          `yaml:"env,omitempty"`
+	SharedCredentials *SharedCredentialsConfig `yaml:"shared_credentials,omitempty"`
+	SSO               *SSOCredentials          `yaml:"sso,omitempty"`
+	AssumeRole        *AssumeRoleCredentials   `yaml:"assume_role,omitempty"`
+	IMDS              *IMDSCredentials         `yaml:"imds,omitempty"`
+}
+
+// StaticCredentials holds a plaintext access key pair, equivalent to the legacy top-level Profile fields but nested
+// under the credentials block.
+type StaticCredentials struct {
+	AccessKeyId     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token,omitempty"`
+}
+
+// EnvCredentials reads credentials from the standard AWS environment variables.
+type EnvCredentials struct{}
+
+// SharedCredentialsConfig reads credentials from an AWS shared credentials file.
+type SharedCredentialsConfig struct {
+	File    string `yaml:"file,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+}
+
+// SSOCredentials reads credentials from an AWS IAM Identity Center (SSO) session.
+type SSOCredentials struct {
+	StartURL  string `yaml:"start_url"`
+	AccountId string `yaml:"account_id"`
+	RoleName  string `yaml:"role_name"`
+	Region    string `yaml:"region,omitempty"`
+}
+
+// AssumeRoleCredentials assumes an IAM role, optionally from a named source profile and/or behind an MFA prompt.
+type AssumeRoleCredentials struct {
+	SourceProfile string `yaml:"source_profile,omitempty"`
+	RoleArn       string `yaml:"role_arn"`
+	ExternalId    string `yaml:"external_id,omitempty"`
+	MFASerial     string `yaml:"mfa_serial,omitempty"`
+
+	// SessionDuration is a Go duration string (e.g. "1h"). yaml.v2 has no native duration type, so this is parsed
+	// with time.ParseDuration rather than decoded directly.
+	SessionDuration string `yaml:"session_duration,omitempty"`
+}
+
+// IMDSCredentials reads credentials from the EC2 instance metadata service, for when lanes itself runs on an
+// instance with an attached role.
+type IMDSCredentials struct{}
+
+// validate checks that exactly one provider block is set, so a misconfigured credentials stanza is rejected by
+// Profile.Validate instead of only failing later, at Activate time.
+func (this *Credentials) validate() error {
+	set := 0
+	for _, configured := range []bool{
+		this.Static != nil,
+		this.Env != nil,
+		this.SharedCredentials != nil,
+		this.SSO != nil,
+		this.AssumeRole != nil,
+		this.IMDS != nil,
+	} {
+		if configured {
+			set++
+		}
+	}
+
+	if set == 0 {
+		return ErrNoCredentialsConfigured
+	} else if set > 1 {
+		return fmt.Errorf("more than one credentials provider configured")
+	}
+
+	return nil
+}
+
+// Resolve builds an AWS credentials.Credentials chain for whichever provider is configured, returning
+// ErrNoCredentialsConfigured if none is set.
+func (this *Credentials) Resolve(sess *session.Session) (*credentials.Credentials, error) {
+	switch {
+	case this == nil:
+		return nil, ErrNoCredentialsConfigured
+
+	case this.Static != nil:
+		return credentials.NewStaticCredentials(this.Static.AccessKeyId, this.Static.SecretAccessKey, this.Static.SessionToken), nil
+
+	case this.Env != nil:
+		return credentials.NewEnvCredentials(), nil
+
+	case this.SharedCredentials != nil:
+		return credentials.NewSharedCredentials(this.SharedCredentials.File, this.SharedCredentials.Profile), nil
+
+	case this.SSO != nil:
+		ssoSess := sess
+		if this.SSO.Region != "" {
+			ssoSess = sess.Copy(&aws.Config{Region: aws.String(this.SSO.Region)})
+		}
+		return ssocreds.NewCredentials(ssoSess, this.SSO.AccountId, this.SSO.RoleName, this.SSO.StartURL), nil
+
+	case this.AssumeRole != nil:
+		var duration time.Duration
+		if this.AssumeRole.SessionDuration != "" {
+			var err error
+			if duration, err = time.ParseDuration(this.AssumeRole.SessionDuration); err != nil {
+				return nil, fmt.Errorf("invalid assume_role session_duration %q: %s", this.AssumeRole.SessionDuration, err)
+			}
+		}
+
+		assumeSess := sess
+		if this.AssumeRole.SourceProfile != "" {
+			var err error
+			if assumeSess, err = session.NewSessionWithOptions(session.Options{
+				SharedConfigState: session.SharedConfigEnable,
+				Profile:           this.AssumeRole.SourceProfile,
+			}); err != nil {
+				return nil, fmt.Errorf("unable to load source_profile %q: %s", this.AssumeRole.SourceProfile, err)
+			}
+		}
+
+		return stscreds.NewCredentials(assumeSess, this.AssumeRole.RoleArn, func(p *stscreds.AssumeRoleProvider) {
+			if this.AssumeRole.ExternalId != "" {
+				p.ExternalID = &this.AssumeRole.ExternalId
+			}
+			if this.AssumeRole.MFASerial != "" {
+				p.SerialNumber = &this.AssumeRole.MFASerial
+			}
+			if duration > 0 {
+				p.Duration = duration
+			}
+		}), nil
+
+	case this.IMDS != nil:
+		return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{
+			Client: ec2metadata.New(sess),
+		}), nil
+
+	default:
+		return nil, ErrNoCredentialsConfigured
+	}
+}
+
+// profileHoldsSecretMaterial reports whether the profile YAML at path stores any plaintext secret (a static access
+// key, or a credentials block with a static/shared_credentials provider). SSO, assume-role, IMDS, and env-based
+// providers don't keep secrets on disk, so permission warnings are unnecessary noise for them.
+func profileHoldsSecretMaterial(path string) bool {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		// err on the side of caution if we can't inspect the file
+		return true
+	}
+
+	var prof Profile
+	if err := yaml.Unmarshal(in, &prof); err != nil {
+		return true
+	}
+
+	if prof.AWSAccessKeyId != "" || prof.AWSSecretAccessKey != "" {
+		return true
+	}
+
+	if c := prof.Credentials; c != nil && (c.Static != nil || c.SharedCredentials != nil) {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package lanes
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EncryptionConfig describes how a profile should be encrypted at rest, either via its own encryption stanza or
+// implied by the .age/.sops.yml suffix on its filename.
+type EncryptionConfig struct {
+	Method     string   `yaml:"method,omitempty"` // "age" or "sops"
+	Recipients []string `yaml:"recipients,omitempty"`
+	Identity   string   `yaml:"identity,omitempty"`
+}
+
+// metaPath returns the path to the plaintext sidecar file that records how the profile at path is encrypted. It
+// holds no secrets (just a method name, age/KMS/PGP recipients, and an identity file path), so LoadProfile can
+// detect and decrypt a stanza-configured profile without having to parse its still-encrypted contents.
+func metaPath(path string) string {
+	return path + ".meta.yml"
+}
+
+// writeEncryptionMeta persists enc's method/recipients/identity to path's plaintext sidecar file.
+func writeEncryptionMeta(path string, enc *EncryptionConfig) error {
+	out, err := yaml.Marshal(enc)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(metaPath(path), out, 0644)
+}
+
+// readEncryptionMeta reads path's sidecar file, if one exists. It returns a nil *EncryptionConfig, with no error,
+// when there is no sidecar (i.e. the profile isn't stanza-encrypted).
+func readEncryptionMeta(path string) (*EncryptionConfig, error) {
+	in, err := ioutil.ReadFile(metaPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	enc := new(EncryptionConfig)
+	if err := yaml.Unmarshal(in, enc); err != nil {
+		return nil, fmt.Errorf("unable to parse encryption metadata %q: %s", metaPath(path), err)
+	}
+
+	return enc, nil
+}
+
+// detectEncryptionSuffix figures out whether path's filename alone implies an encrypted profile, returning the
+// method to use ("age", "sops", or "" if the suffix doesn't match either).
+func detectEncryptionSuffix(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".sops.yml"), strings.HasSuffix(path, ".sops.yaml"):
+		return "sops"
+	case strings.HasSuffix(path, ".age"):
+		return "age"
+	default:
+		return ""
+	}
+}
+
+// detectEncryptionMethod returns the encryption method to use for path, preferring enc's own Method (set either by
+// an in-memory profile's encryption stanza or recovered from a sidecar via readEncryptionMeta) and falling back to
+// a filename suffix. enc may be nil.
+func detectEncryptionMethod(enc *EncryptionConfig, path string) string {
+	if enc != nil && enc.Method != "" {
+		return enc.Method
+	}
+
+	return detectEncryptionSuffix(path)
+}
+
+// encryptEnvelope wraps plaintext YAML bytes using method. lanes shells out to the age/sops CLIs rather than
+// vendoring a full crypto stack, so the user's existing age/sops setup (identities, KMS access, ...) just works.
+func encryptEnvelope(method string, plaintext []byte, enc *EncryptionConfig) ([]byte, error) {
+	switch method {
+	case "age":
+		args := []string{"--encrypt", "--armor"}
+		for _, r := range enc.Recipients {
+			args = append(args, "-r", r)
+		}
+		return runFilter("age", args, plaintext)
+
+	case "sops":
+		args := []string{"--encrypt", "--input-type", "yaml", "--output-type", "yaml"}
+		for _, r := range enc.Recipients {
+			args = append(args, sopsRecipientFlag(r), r)
+		}
+		args = append(args, "/dev/stdin")
+		return runFilter("sops", args, plaintext)
+
+	default:
+		return nil, fmt.Errorf("unknown encryption method %q", method)
+	}
+}
+
+// sopsRecipientFlag maps a recipient string to the sops CLI flag that identifies its kind: an AWS KMS key ARN, an
+// age public key (starting with "age1"), or (by default) a PGP fingerprint.
+func sopsRecipientFlag(recipient string) string {
+	switch {
+	case strings.HasPrefix(recipient, "arn:aws:kms:"):
+		return "--kms"
+	case strings.HasPrefix(recipient, "age1"):
+		return "--age"
+	default:
+		return "--pgp"
+	}
+}
+
+// decryptEnvelope reverses encryptEnvelope.
+func decryptEnvelope(method string, ciphertext []byte, enc *EncryptionConfig) ([]byte, error) {
+	switch method {
+	case "age":
+		args := []string{"--decrypt"}
+		if enc != nil && enc.Identity != "" {
+			args = append(args, "-i", enc.Identity)
+		}
+		return runFilter("age", args, ciphertext)
+
+	case "sops":
+		return runFilter("sops", []string{"--decrypt", "--input-type", "yaml", "--output-type", "yaml", "/dev/stdin"}, ciphertext)
+
+	default:
+		return nil, fmt.Errorf("unknown encryption method %q", method)
+	}
+}
+
+// runFilter pipes in through the named command's stdin and returns its stdout.
+func runFilter(name string, args []string, in []byte) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(in)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %s", name, err)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package lanes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// RunOptions configures how RunInLane executes a command across a lane.
+type RunOptions struct {
+	// Parallelism bounds how many hosts are contacted concurrently. A value <= 0 defaults to 5.
+	Parallelism int
+
+	// FailFast stops dispatching to additional hosts as soon as one returns a non-zero exit code or error.
+	FailFast bool
+
+	// Upload, if set, is a local file or directory copied to each target (via scp) before cmd runs.
+	Upload string
+
+	// UploadDest is the remote destination path for Upload. Defaults to the remote home directory.
+	UploadDest string
+}
+
+// RunResult captures the outcome of running a command against a single server.
+type RunResult struct {
+	Server   *Server
+	ExitCode int
+	Err      error
+}
+
+// RunInLane resolves every server in lane, then runs cmd over SSH on each one concurrently, honoring
+// opts.Parallelism and opts.FailFast. Output from each host is streamed to stdout as it arrives, prefixed with the
+// host's name so concurrent hosts don't interleave mid-line.
+func (this *Profile) RunInLane(svc *ec2.EC2, lane, cmd string, opts RunOptions) ([]RunResult, error) {
+	servers, err := this.FetchServersInLane(svc, lane)
+	if err != nil {
+		return nil, err
+	}
+
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = 5
+	}
+
+	var (
+		results = make([]RunResult, len(servers))
+		sem     = make(chan struct{}, parallelism)
+		wg      sync.WaitGroup
+		abort   = make(chan struct{})
+		once    sync.Once
+	)
+
+	for i, svr := range servers {
+		// take a worker slot before checking for an abort, so a host waiting on a slot isn't dispatched after the
+		// failure that freed it
+		sem <- struct{}{}
+
+		select {
+		case <-abort:
+			<-sem
+			results[i] = RunResult{Server: svr, Err: fmt.Errorf("skipped after an earlier failure")}
+			continue
+		default:
+		}
+
+		wg.Add(1)
+
+		go func(i int, svr *Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := this.runOne(svr, cmd, opts)
+			results[i] = result
+
+			if opts.FailFast && (result.Err != nil || result.ExitCode != 0) {
+				once.Do(func() { close(abort) })
+			}
+		}(i, svr)
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+// runOne uploads opts.Upload (if set) and runs cmd on a single server over SSH.
+func (this *Profile) runOne(svr *Server, cmd string, opts RunOptions) RunResult {
+	if svr.profile == nil {
+		return RunResult{Server: svr, Err: fmt.Errorf("no ssh profile resolved for %s", svr)}
+	}
+
+	if err := svr.profile.EnsureCertificate(); err != nil {
+		return RunResult{Server: svr, Err: fmt.Errorf("unable to issue certificate: %s", err)}
+	}
+
+	if opts.Upload != "" {
+		if err := this.uploadTo(svr, opts.Upload, opts.UploadDest); err != nil {
+			return RunResult{Server: svr, Err: fmt.Errorf("upload failed: %s", err)}
+		}
+	}
+
+	args := append(svr.profile.BuildArgs(svr.Host()), cmd)
+	c := exec.Command("ssh", args...)
+
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return RunResult{Server: svr, Err: err}
+	}
+
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return RunResult{Server: svr, Err: err}
+	}
+
+	if err := c.Start(); err != nil {
+		return RunResult{Server: svr, Err: err}
+	}
+
+	var streamWg sync.WaitGroup
+	streamWg.Add(2)
+	go streamPrefixed(svr, stdout, &streamWg)
+	go streamPrefixed(svr, stderr, &streamWg)
+	streamWg.Wait()
+
+	exitCode := 0
+	if err := c.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			return RunResult{Server: svr, Err: err}
+		}
+	}
+
+	return RunResult{Server: svr, ExitCode: exitCode}
+}
+
+// streamPrefixed copies r to stdout a line at a time, prefixed with svr's name.
+func streamPrefixed(svr *Server, r io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fmt.Printf("[%s] %s\n", svr, scanner.Text())
+	}
+}
+
+// uploadTo copies local to dest on svr via scp before the command runs.
+func (this *Profile) uploadTo(svr *Server, local, dest string) error {
+	if dest == "" {
+		dest = "."
+	}
+
+	// -r so that a directory can be uploaded as well as a single file
+	args := append([]string{"-r"}, svr.profile.BuildScpArgs()...)
+	args = append(args, local, fmt.Sprintf("%s:%s", svr.Host(), dest))
+	return exec.Command("scp", args...).Run()
+}
@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/go-multierror"
 	"gopkg.in/yaml.v2"
@@ -14,14 +17,24 @@ import (
 )
 
 type Profile struct {
-	AWSAccessKeyId     string `yaml:"aws_access_key_id"`
-	AWSSecretAccessKey string `yaml:"aws_secret_access_key"`
+	AWSAccessKeyId     string `yaml:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey string `yaml:"aws_secret_access_key,omitempty"`
 	Region             string `yaml:"region,omitempty"`
 
+	// Credentials, when set, takes precedence over AWSAccessKeyId/AWSSecretAccessKey and describes a provider chain
+	// (SSO, assume-role, IMDS, shared config, ...) to use instead of a plaintext key pair.
+	Credentials *Credentials `yaml:"credentials,omitempty"`
+
+	// Encryption, when set (or implied by a .age/.sops.yml file extension), causes WriteFile/LoadProfile to wrap
+	// the profile's YAML in an age or SOPS envelope.
+	Encryption *EncryptionConfig `yaml:"encryption,omitempty"`
+
 	SSH ssh.Config `yaml:"ssh"`
 
-	global    *Config
-	overwrite bool
+	global     *Config
+	overwrite  bool
+	awsConfig  *aws.Config
+	secretRefs []secretBinding
 }
 
 // GetSampleProfile returns a sample profile that is easy to use as an example.
@@ -62,6 +75,13 @@ func GetProfilePath(name string, checkPerms bool) string {
 
 // CheckProfilePermissions looks for any concerns with permissions that are too permissible for Lanes profiles.
 func CheckProfilePermissions(path string) {
+	// an encrypted profile isn't a secret on disk regardless of its mode, and profiles whose credentials are
+	// resolved through a provider (SSO, assume-role, IMDS, ...) don't hold secrets either
+	enc, _ := readEncryptionMeta(path)
+	if detectEncryptionMethod(enc, path) != "" || !profileHoldsSecretMaterial(path) {
+		return
+	}
+
 	var result error
 
 	// check the directory first
@@ -120,19 +140,72 @@ func CheckPermissions(path string) (fatal bool, result error) {
 	return
 }
 
-// LoadProfile attempts to read the specified profile from the filesystem.
+// LoadProfile attempts to read the specified profile from the filesystem, transparently decrypting it first if its
+// encryption sidecar or its filename implies an age or SOPS envelope.
 func LoadProfile(name string) (prof *Profile, err error) {
 	var in []byte
 
-	if in, err = ioutil.ReadFile(GetProfilePath(name, true)); err != nil {
+	path := GetProfilePath(name, true)
+	if in, err = ioutil.ReadFile(path); err != nil {
+		err = fmt.Errorf("unable to read profile: %s", err)
+		return
+	}
+
+	// recover the encryption stanza from the plaintext sidecar, since it can't be read out of the (still
+	// encrypted) profile bytes themselves
+	var enc *EncryptionConfig
+	if enc, err = readEncryptionMeta(path); err != nil {
 		err = fmt.Errorf("unable to read profile: %s", err)
 		return
 	}
 
+	if method := detectEncryptionMethod(enc, path); method != "" {
+		if in, err = decryptEnvelope(method, in, enc); err != nil {
+			err = fmt.Errorf("unable to decrypt profile: %s", err)
+			return
+		}
+	}
+
 	return LoadProfileBytes(in)
 }
 
-// LoadProfileBytes loads the currently configured lane profile from the specified YAML bytes.
+// LoadProfileRaw behaves like LoadProfile but skips secretref resolution and Validate, for callers — such as shell
+// completion — that only need structural fields like lane names and shouldn't trigger secret backend prompts
+// (Vault/1Password/keyring unlocks) or require a fully valid profile.
+func LoadProfileRaw(name string) (prof *Profile, err error) {
+	path := GetProfilePath(name, false)
+
+	var in []byte
+	if in, err = ioutil.ReadFile(path); err != nil {
+		err = fmt.Errorf("unable to read profile: %s", err)
+		return
+	}
+
+	var enc *EncryptionConfig
+	if enc, err = readEncryptionMeta(path); err != nil {
+		err = fmt.Errorf("unable to read profile: %s", err)
+		return
+	}
+
+	if method := detectEncryptionMethod(enc, path); method != "" {
+		if in, err = decryptEnvelope(method, in, enc); err != nil {
+			err = fmt.Errorf("unable to decrypt profile: %s", err)
+			return
+		}
+	}
+
+	prof = new(Profile)
+	if err = yaml.Unmarshal(in, prof); err != nil {
+		err = fmt.Errorf("unable to parse lane profile: %s", err)
+		return
+	}
+
+	return prof, nil
+}
+
+// LoadProfileBytes loads the currently configured lane profile from the specified YAML bytes. Any string value
+// matching the secretref URI grammar (e.g. "keyring:lanes/prod/aws_secret") is resolved to its plaintext in place
+// before Validate runs.
 func LoadProfileBytes(in []byte) (prof *Profile, err error) {
 	prof = new(Profile)
 	if err = yaml.Unmarshal(in, prof); err != nil {
@@ -140,6 +213,11 @@ func LoadProfileBytes(in []byte) (prof *Profile, err error) {
 		return
 	}
 
+	if prof.secretRefs, err = resolveSecretRefs(reflect.ValueOf(prof)); err != nil {
+		err = fmt.Errorf("unable to resolve secret references: %s", err)
+		return
+	}
+
 	// allow the profile to access global configuration values
 	prof.global = config
 
@@ -158,11 +236,17 @@ func (this *Profile) SetOverwrite(value bool) {
 
 // Validate checks that the profile includes the necessary information to interact with AWS.
 func (this *Profile) Validate() error {
-	if this.AWSAccessKeyId == "" {
-		return ErrMissingAccessKey
-	}
-
-	if this.AWSSecretAccessKey == "" {
+	if this.Credentials != nil {
+		// a credentials block is an acceptable substitute for the plaintext static keys, but it must actually
+		// resolve to a single provider, whether or not static keys are also present: Activate prefers Credentials
+		// over the static keys, so a malformed block here would otherwise only fail later, at Activate time
+		if err := this.Credentials.validate(); err != nil {
+			return err
+		}
+	} else if this.AWSAccessKeyId == "" || this.AWSSecretAccessKey == "" {
+		if this.AWSAccessKeyId == "" {
+			return ErrMissingAccessKey
+		}
 		return ErrMissingSecretKey
 	}
 
@@ -177,8 +261,26 @@ func (this *Profile) Validate() error {
 	return nil
 }
 
-// Activate sets some environment variables to access AWS using a given profile.
+// Activate prepares this profile for use, either by resolving its credentials provider chain into an *aws.Config
+// (see AWSConfig) or, for legacy static-key profiles, by setting the usual AWS environment variables.
 func (this *Profile) Activate() {
+	if this.Credentials != nil {
+		sess, err := session.NewSession()
+		if err != nil {
+			fmt.Printf("ERROR: unable to start AWS session: %s\n", err)
+			os.Exit(1)
+		}
+
+		creds, err := this.Credentials.Resolve(sess)
+		if err != nil {
+			fmt.Printf("ERROR: unable to resolve credentials: %s\n", err)
+			os.Exit(1)
+		}
+
+		this.awsConfig = &aws.Config{Region: aws.String(this.Region), Credentials: creds}
+		return
+	}
+
 	os.Setenv("AWS_ACCESS_KEY_ID", this.AWSAccessKeyId)
 	os.Setenv("AWS_SECRET_ACCESS_KEY", this.AWSSecretAccessKey)
 }
@@ -187,6 +289,28 @@ func (this *Profile) Activate() {
 func (this *Profile) Deactivate() {
 	os.Unsetenv("AWS_ACCESS_KEY_ID")
 	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	this.awsConfig = nil
+}
+
+// AWSConfig returns the *aws.Config resolved by Activate when this profile uses the credentials block. It is nil
+// for profiles that rely on the legacy environment-variable activation, in which case callers should build a
+// session the usual way and let the SDK pick up the environment.
+func (this *Profile) AWSConfig() *aws.Config {
+	return this.awsConfig
+}
+
+// NewEC2Client builds an *ec2.EC2 client for this profile, activating it first if that hasn't happened yet.
+func (this *Profile) NewEC2Client() (*ec2.EC2, error) {
+	if this.awsConfig == nil {
+		this.Activate()
+	}
+
+	sess, err := session.NewSession(this.awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start AWS session: %s", err)
+	}
+
+	return ec2.New(sess), nil
 }
 
 // FetchServers retrieves all EC2 instances for the current profile.
@@ -200,7 +324,8 @@ func (this *Profile) FetchServersInLane(svc *ec2.EC2, lane string) ([]*Server, e
 }
 
 // FetchServersBy retrieves all EC2 instances for the current profile using any specified filters. Each instance is
-// automatically tagged with the appropriate SSH profile to access it.
+// automatically tagged with its resolved effective SSH profile (including any config-level bastion chain) so it
+// can be reached without further lookups.
 func (this *Profile) FetchServersBy(svc *ec2.EC2, input *ec2.DescribeInstancesInput) (servers []*Server, err error) {
 	var exists bool
 
@@ -209,7 +334,7 @@ func (this *Profile) FetchServersBy(svc *ec2.EC2, input *ec2.DescribeInstancesIn
 	}
 
 	for _, svr := range servers {
-		if svr.profile, exists = this.SSH.Mods[svr.Lane]; !exists {
+		if svr.profile, exists = this.SSH.Resolve(svr.Lane); !exists {
 			fmt.Printf("WARNING: no profile found for %s in lane %q\n", svr, svr.Lane)
 		}
 	}
@@ -235,11 +360,31 @@ func (this *Profile) WriteFile(name, dest string) (err error) {
 		return
 	}
 
-	// make sure the destination directory exists
+	// make sure the destination directory exists before anything is written into it, including the encryption
+	// sidecar below
 	if err = os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
 		return
 	}
 
+	if method := detectEncryptionMethod(this.Encryption, dest); method != "" {
+		enc := this.Encryption
+		if enc == nil {
+			enc = &EncryptionConfig{Method: method}
+		} else if enc.Method == "" {
+			enc.Method = method
+		}
+
+		if out, err = encryptEnvelope(method, out, enc); err != nil {
+			return fmt.Errorf("unable to encrypt profile: %s", err)
+		}
+
+		// record the method/recipients/identity in a plaintext sidecar so LoadProfile can detect and decrypt this
+		// profile later without needing to parse its ciphertext
+		if err = writeEncryptionMeta(dest, enc); err != nil {
+			return fmt.Errorf("unable to write encryption metadata: %s", err)
+		}
+	}
+
 	if err = ioutil.WriteFile(dest, out, 0600); err != nil {
 		return
 	}
@@ -249,7 +394,20 @@ func (this *Profile) WriteFile(name, dest string) (err error) {
 	return nil
 }
 
-// WriteBytes marshals the current settings to YAML.
+// WriteBytes marshals the current settings to YAML. Fields resolved from a secretref URI are temporarily swapped
+// back to their original reference for the duration of the marshal, so secrets are never inlined on disk.
 func (this *Profile) WriteBytes() ([]byte, error) {
-	return yaml.Marshal(this)
+	resolved := make([]string, len(this.secretRefs))
+	for i, b := range this.secretRefs {
+		resolved[i] = b.target.String()
+		b.target.SetString(b.original)
+	}
+
+	out, err := yaml.Marshal(this)
+
+	for i, b := range this.secretRefs {
+		b.target.SetString(resolved[i])
+	}
+
+	return out, err
 }
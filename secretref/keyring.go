@@ -0,0 +1,35 @@
+package secretref
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/99designs/keyring"
+)
+
+// keyringBackend resolves "keyring:service/key" references against the OS-native keychain (macOS Keychain, Secret
+// Service on Linux, Windows Credential Manager).
+type keyringBackend struct{}
+
+func init() {
+	Register("keyring", keyringBackend{})
+}
+
+func (keyringBackend) Resolve(ref string) (string, error) {
+	service, key, ok := strings.Cut(ref, "/")
+	if !ok {
+		return "", fmt.Errorf("keyring reference %q must be service/key", ref)
+	}
+
+	ring, err := keyring.Open(keyring.Config{ServiceName: service})
+	if err != nil {
+		return "", fmt.Errorf("unable to open keyring for %q: %s", service, err)
+	}
+
+	item, err := ring.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %q from keyring: %s", ref, err)
+	}
+
+	return string(item.Data), nil
+}
@@ -0,0 +1,24 @@
+package secretref
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// onePasswordBackend resolves "op://vault/item/field" references by shelling out to the 1Password CLI (`op read`),
+// which handles the user's own session/biometric unlock.
+type onePasswordBackend struct{}
+
+func init() {
+	Register("op", onePasswordBackend{})
+}
+
+func (onePasswordBackend) Resolve(ref string) (string, error) {
+	out, err := exec.Command("op", "read", "op://"+ref).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to read %q via op CLI: %s", ref, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
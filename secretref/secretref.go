@@ -0,0 +1,74 @@
+// Package secretref resolves secret reference URIs (e.g. "keyring:lanes/prod/aws_secret",
+// "vault:secret/data/aws/prod#secret_access_key", "op://vault/item/field", "env:VAR_NAME") against a pluggable set
+// of backends, so lanes profiles can point at an external secret store instead of holding plaintext.
+package secretref
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Backend resolves a secret reference's scheme-specific remainder to its plaintext value.
+type Backend interface {
+	Resolve(ref string) (string, error)
+}
+
+var backends = map[string]Backend{}
+
+// Register installs a Backend for the given URI scheme (e.g. "keyring", "vault", "op", "env").
+func Register(scheme string, backend Backend) {
+	backends[scheme] = backend
+}
+
+// IsRef reports whether value looks like a secret reference this package knows how to resolve.
+func IsRef(value string) bool {
+	scheme, _, ok := split(value)
+	if !ok {
+		return false
+	}
+
+	_, known := backends[scheme]
+	return known
+}
+
+// Resolve looks up the plaintext value for a secret reference.
+func Resolve(value string) (string, error) {
+	scheme, rest, ok := split(value)
+	if !ok {
+		return "", fmt.Errorf("%q is not a secret reference", value)
+	}
+
+	backend, known := backends[scheme]
+	if !known {
+		return "", fmt.Errorf("no secretref backend registered for scheme %q", scheme)
+	}
+
+	return backend.Resolve(rest)
+}
+
+// split parses the scheme and remainder out of a reference, accepting both "scheme:rest" and "scheme://rest".
+func split(value string) (scheme, rest string, ok bool) {
+	idx := strings.Index(value, ":")
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	return value[:idx], strings.TrimPrefix(value[idx+1:], "//"), true
+}
+
+func init() {
+	Register("env", envBackend{})
+}
+
+// envBackend resolves "env:VAR_NAME" references to the named environment variable.
+type envBackend struct{}
+
+func (envBackend) Resolve(ref string) (string, error) {
+	val, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", ref)
+	}
+
+	return val, nil
+}
@@ -0,0 +1,72 @@
+package secretref
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// vaultBackend resolves "vault:path/to/secret#field" references against HashiCorp Vault, authenticating with
+// VAULT_TOKEN or, if that's unset, the AppRole credentials in VAULT_ROLE_ID/VAULT_SECRET_ID.
+type vaultBackend struct{}
+
+func init() {
+	Register("vault", vaultBackend{})
+}
+
+func (vaultBackend) Resolve(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok {
+		return "", fmt.Errorf("vault reference %q must include a #field", ref)
+	}
+
+	client, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	if err != nil {
+		return "", fmt.Errorf("unable to build vault client: %s", err)
+	}
+
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		client.SetToken(token)
+	} else if err := approleLogin(client); err != nil {
+		return "", err
+	}
+
+	secret, err := client.Logical().Read(path)
+	if err != nil || secret == nil {
+		return "", fmt.Errorf("unable to read vault secret %q: %s", path, err)
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		data = secret.Data
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %q has no field %q", path, field)
+	}
+
+	return value, nil
+}
+
+// approleLogin authenticates client using the AppRole credentials in VAULT_ROLE_ID/VAULT_SECRET_ID.
+func approleLogin(client *vaultapi.Client) error {
+	roleId := os.Getenv("VAULT_ROLE_ID")
+	secretId := os.Getenv("VAULT_SECRET_ID")
+	if roleId == "" || secretId == "" {
+		return fmt.Errorf("no VAULT_TOKEN and no VAULT_ROLE_ID/VAULT_SECRET_ID to authenticate with vault")
+	}
+
+	secret, err := client.Logical().Write("auth/approle/login", map[string]interface{}{
+		"role_id":   roleId,
+		"secret_id": secretId,
+	})
+	if err != nil || secret == nil || secret.Auth == nil {
+		return fmt.Errorf("unable to authenticate with vault via approle: %s", err)
+	}
+
+	client.SetToken(secret.Auth.ClientToken)
+	return nil
+}
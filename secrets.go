@@ -0,0 +1,133 @@
+package lanes
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/codekoala/go-aws-lanes/secretref"
+)
+
+// secretBinding records a struct field that resolveSecretRefs resolved in place, so WriteBytes can swap the
+// original reference back in before marshalling.
+type secretBinding struct {
+	target   reflect.Value
+	original string
+}
+
+// resolveSecretRefs walks v's exported string fields recursively and, in place, replaces any value matching the
+// secretref URI grammar with its resolved plaintext. It returns the bindings it resolved so the caller can restore
+// the original references later (see Profile.WriteBytes).
+func resolveSecretRefs(v reflect.Value) ([]secretBinding, error) {
+	var bindings []secretBinding
+
+	var walk func(reflect.Value) error
+	walk = func(v reflect.Value) error {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				return nil
+			}
+			return walk(v.Elem())
+
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Field(i)
+				if !f.CanSet() {
+					continue
+				}
+				if err := walk(f); err != nil {
+					return err
+				}
+			}
+
+		case reflect.Map:
+			for _, key := range v.MapKeys() {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				elem.Set(v.MapIndex(key))
+				if err := walk(elem); err != nil {
+					return err
+				}
+				v.SetMapIndex(key, elem)
+			}
+
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := walk(v.Index(i)); err != nil {
+					return err
+				}
+			}
+
+		case reflect.String:
+			if s := v.String(); secretref.IsRef(s) {
+				resolved, err := secretref.Resolve(s)
+				if err != nil {
+					return err
+				}
+				bindings = append(bindings, secretBinding{target: v, original: s})
+				v.SetString(resolved)
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(v); err != nil {
+		return nil, err
+	}
+
+	return bindings, nil
+}
+
+// SetSecretRef points the field at the given dot-separated YAML path (e.g. "credentials.static.secret_access_key")
+// at a secretref URI, without resolving it, so it can be written back to disk as a reference.
+func (this *Profile) SetSecretRef(path, ref string) error {
+	if err := setByYAMLPath(reflect.ValueOf(this), strings.Split(path, "."), ref); err != nil {
+		return err
+	}
+
+	// if the field was itself loaded from a reference, make WriteBytes write the new reference, not the old one
+	for i := range this.secretRefs {
+		if this.secretRefs[i].target.String() == ref {
+			this.secretRefs[i].original = ref
+		}
+	}
+
+	return nil
+}
+
+// setByYAMLPath descends v by matching each path component against struct fields' `yaml` tags, and sets the final
+// field (which must be a string) to value.
+func setByYAMLPath(v reflect.Value, path []string, value string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setByYAMLPath(v.Elem(), path, value)
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot descend into %s for path %q", v.Kind(), path[0])
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != path[0] {
+			continue
+		}
+
+		field := v.Field(i)
+		if len(path) == 1 {
+			if field.Kind() != reflect.String {
+				return fmt.Errorf("field %q is not a string", path[0])
+			}
+			field.SetString(value)
+			return nil
+		}
+
+		return setByYAMLPath(field, path[1:], value)
+	}
+
+	return fmt.Errorf("no field tagged %q", path[0])
+}
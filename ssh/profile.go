@@ -0,0 +1,153 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// Config holds the ssh-specific settings for a lanes profile, keyed by lane name.
+type Config struct {
+	Mods map[string]*Profile `yaml:"mods"`
+
+	// DefaultProxyJump is used as the bastion chain for any lane profile that doesn't set its own ProxyJump.
+	DefaultProxyJump []string `yaml:"default_proxy_jump,omitempty"`
+}
+
+// Resolve returns the effective ssh.Profile for the named lane, merging in config-level defaults (such as a shared
+// bastion chain) that the lane-specific profile didn't override. The returned Profile is a shallow copy: reassigning
+// its fields does not affect the configured Mods, but its slices share their elements with the configuration.
+func (this *Config) Resolve(lane string) (*Profile, bool) {
+	prof, exists := this.Mods[lane]
+	if !exists {
+		return nil, false
+	}
+
+	resolved := *prof
+	if len(resolved.ProxyJump) == 0 {
+		resolved.ProxyJump = this.DefaultProxyJump
+	}
+
+	return &resolved, true
+}
+
+// Profile describes how to reach and authenticate to servers in a single lane.
+type Profile struct {
+	Identity string   `yaml:"identity,omitempty"`
+	Tunnel   string   `yaml:"tunnel,omitempty"`
+	Tunnels  []string `yaml:"tunnels,omitempty"`
+
+	// ProxyJump lists bastion hops ("user@host:port") to traverse before dialing the target, in order, for
+	// instances that only have a private IP.
+	ProxyJump []string `yaml:"proxy_jump,omitempty"`
+
+	// KnownHostsFile and StrictHostKeyChecking are passed through as the equivalent ssh_config options.
+	KnownHostsFile        string `yaml:"known_hosts_file,omitempty"`
+	StrictHostKeyChecking string `yaml:"strict_host_key_checking,omitempty"`
+
+	// CertificateFile points to a signed OpenSSH certificate to present alongside Identity. CertIssuer, if set, is
+	// run before connecting to mint a short-lived certificate (e.g. an aws-vault or ssm invocation); its stdout is
+	// written to CertificateFile.
+	CertificateFile string `yaml:"certificate_file,omitempty"`
+	CertIssuer      string `yaml:"cert_issuer,omitempty"`
+}
+
+// AllTunnels returns every configured tunnel, whether set via the singular Tunnel field or the Tunnels slice.
+func (this *Profile) AllTunnels() []string {
+	tunnels := this.Tunnels
+	if this.Tunnel != "" {
+		tunnels = append([]string{this.Tunnel}, tunnels...)
+	}
+	return tunnels
+}
+
+// IssueCertificate runs the configured CertIssuer command and returns the signed certificate it writes to stdout.
+// It is a no-op, returning nil, when no issuer command is configured.
+func (this *Profile) IssueCertificate() ([]byte, error) {
+	if this.CertIssuer == "" {
+		return nil, nil
+	}
+
+	parts := strings.Fields(this.CertIssuer)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("cert_issuer is configured but empty")
+	}
+
+	out, err := exec.Command(parts[0], parts[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run cert_issuer %q: %s", this.CertIssuer, err)
+	}
+
+	return out, nil
+}
+
+// EnsureCertificate runs CertIssuer (if configured) and writes the certificate it mints to CertificateFile, so a
+// subsequent BuildArgs/BuildScpArgs call has a fresh, signed certificate to present. Callers should invoke this
+// before connecting; it is a no-op when CertIssuer or CertificateFile isn't set.
+func (this *Profile) EnsureCertificate() error {
+	if this.CertIssuer == "" || this.CertificateFile == "" {
+		return nil
+	}
+
+	cert, err := this.IssueCertificate()
+	if err != nil {
+		return err
+	}
+
+	if cert == nil {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(this.CertificateFile, cert, 0600); err != nil {
+		return fmt.Errorf("unable to write certificate to %q: %s", this.CertificateFile, err)
+	}
+
+	return nil
+}
+
+// sharedArgs builds the ssh(1)/scp(1) flags common to both connecting and file transfer: bastion chain, identity,
+// certificate, and known-hosts handling.
+func (this *Profile) sharedArgs() []string {
+	args := []string{}
+
+	if len(this.ProxyJump) > 0 {
+		args = append(args, "-J", strings.Join(this.ProxyJump, ","))
+	}
+
+	if this.Identity != "" {
+		args = append(args, "-i", this.Identity)
+	}
+
+	if this.CertificateFile != "" {
+		args = append(args, "-o", fmt.Sprintf("CertificateFile=%s", this.CertificateFile))
+	}
+
+	if this.KnownHostsFile != "" {
+		args = append(args, "-o", fmt.Sprintf("UserKnownHostsFile=%s", this.KnownHostsFile))
+	}
+
+	if this.StrictHostKeyChecking != "" {
+		args = append(args, "-o", fmt.Sprintf("StrictHostKeyChecking=%s", this.StrictHostKeyChecking))
+	}
+
+	return args
+}
+
+// BuildArgs assembles the ssh(1) command-line arguments needed to connect to host using this profile, including any
+// bastion hops, certificate-based auth, and tunnels.
+func (this *Profile) BuildArgs(host string) []string {
+	args := this.sharedArgs()
+
+	for _, t := range this.AllTunnels() {
+		args = append(args, "-L", t)
+	}
+
+	return append(args, host)
+}
+
+// BuildScpArgs assembles the scp(1) flags needed to reach a host using this profile's bastion chain and
+// certificate-based auth.
+func (this *Profile) BuildScpArgs() []string {
+	return this.sharedArgs()
+}